@@ -0,0 +1,146 @@
+package executor
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func int64SliceEqual(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWalkParentChain(t *testing.T) {
+	f := newShortestPathFrontier(1)
+	f.parent[2] = 1
+	f.parent[3] = 2
+
+	got := walkParentChain(f, 3)
+	want := []int64{1, 2, 3}
+	if !int64SliceEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestStitchPath(t *testing.T) {
+	forward := newShortestPathFrontier(1)
+	forward.parent[2] = 1
+	forward.parent[3] = 2
+
+	backward := newShortestPathFrontier(5)
+	backward.parent[4] = 5
+	backward.parent[3] = 4
+
+	got := stitchPath(forward, backward, 3)
+	want := []int64{1, 2, 3, 4, 5}
+	if !int64SliceEqual(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+// TestExpandOneHopDrainsAllResultsOnError guards the fix for a reviewer-flagged
+// shutdown bug: when one worker reports an error, expandOneHop must still
+// read exactly len(toExpand) results off resultChan instead of returning as
+// soon as the first error arrives, or any worker still trying to send its
+// result blocks forever (and Close's workerWg.Wait hangs with it).
+func TestExpandOneHopDrainsAllResultsOnError(t *testing.T) {
+	e := &ShortestPathExecutor{}
+	e.execCtx, e.cancel = context.WithCancel(context.Background())
+	defer e.cancel()
+
+	e.workerChan = make(chan *expandTask, 8)
+	e.resultChan = make(chan *expandResult, 8)
+
+	toExpand := []int64{1, 2, 3}
+	e.workerWg.Add(1)
+	go func() {
+		defer e.workerWg.Done()
+		for range toExpand {
+			task := <-e.workerChan
+			if task.vertexID == 2 {
+				e.resultChan <- &expandResult{vertexID: task.vertexID, err: errTestNeighborLookup}
+				continue
+			}
+			e.resultChan <- &expandResult{vertexID: task.vertexID, neighbors: []int64{task.vertexID * 10}}
+		}
+	}()
+
+	f := newShortestPathFrontier(0)
+	done := make(chan struct{})
+	go func() {
+		_, err := e.expandOneHop(e.execCtx, f, toExpand, true)
+		if err != errTestNeighborLookup {
+			t.Errorf("expandOneHop error = %v, want %v", err, errTestNeighborLookup)
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("expandOneHop did not return promptly; a worker is likely blocked sending to resultChan")
+	}
+
+	e.cancel()
+	waitDone := make(chan struct{})
+	go func() {
+		e.workerWg.Wait()
+		close(waitDone)
+	}()
+	select {
+	case <-waitDone:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("workerWg.Wait did not return promptly after cancel")
+	}
+}
+
+type testErr string
+
+func (e testErr) Error() string { return string(e) }
+
+const errTestNeighborLookup = testErr("neighbor lookup failed")
+
+// TestOpenRejectsMultiConditionChain guards the fix for a reviewer-flagged
+// bug: neighbors hardcoded level 0 and silently reused conditionChain[0] for
+// every hop, so a multi-condition chain produced a plausible-looking path
+// that had actually walked the wrong edge type past the first hop. Open now
+// rejects chains longer than 1 outright instead of mis-executing them, and
+// does so before touching e.ctx so this can be driven without a live session.
+func TestOpenRejectsMultiConditionChain(t *testing.T) {
+	e := &ShortestPathExecutor{}
+	e.Init(0, 1, 2, []condition{{edgeID: 1, direction: OUT}, {edgeID: 2, direction: OUT}}, 5)
+
+	if err := e.Open(context.Background()); err == nil {
+		t.Fatalf("expected Open to reject a chain with more than one condition")
+	}
+}
+
+// TestInitWithTargetPredicateRoutesToForwardSearch guards the fix for a
+// reviewer-flagged bug: targetPredicate was declared and checked by
+// computeShortestPaths but had no setter, so it was always nil and
+// forwardSearchUntil was unreachable dead code. InitWithTargetPredicate is
+// the predicate counterpart to Init's fixed-vertex target, and this test
+// drives computeShortestPaths far enough to prove the predicate is actually
+// wired in - the source vertex itself matching the predicate resolves
+// without needing a live snapshot/worker pool.
+func TestInitWithTargetPredicateRoutesToForwardSearch(t *testing.T) {
+	e := &ShortestPathExecutor{}
+	e.InitWithTargetPredicate(0, 7, func(vid int64) bool { return vid == 7 }, nil, 5)
+
+	paths, err := e.computeShortestPaths(context.Background())
+	if err != nil {
+		t.Fatalf("computeShortestPaths() error = %v", err)
+	}
+	want := []int64{7}
+	if len(paths) != 1 || !int64SliceEqual(paths[0], want) {
+		t.Fatalf("computeShortestPaths() = %v, want [%v]", paths, want)
+	}
+}
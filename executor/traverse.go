@@ -2,24 +2,53 @@ package executor
 
 import (
 	"context"
+	"github.com/RoaringBitmap/roaring/roaring64"
 	"github.com/pingcap/parser/mysql"
 	"github.com/pingcap/tidb/kv"
 	plannercore "github.com/pingcap/tidb/planner/core"
 	"github.com/pingcap/tidb/tablecodec"
 	"github.com/pingcap/tidb/util/chunk"
 	"github.com/pingcap/tidb/util/rowcodec"
+	"hash/fnv"
+	"os"
 	"sync"
-	"sync/atomic"
-	"time"
 )
 
 var _ Executor = &TraverseExecutor{}
 
-const workerConcurrency = 5
+const (
+	workerConcurrency = 5
+	// defaultMaxFrontierSize bounds how many in-flight vertex IDs a single
+	// tempResult may carry before handleTraverseTask spills that frontier to
+	// spillFile on disk, so a variable-length hop with a wide fan-out can't grow
+	// submitChan's backlog without bound.
+	defaultMaxFrontierSize = 10000
+	// defaultMaxPendingTasks bounds how many vertex IDs may be in flight
+	// across the whole executor before fetchFromChildAndBuildFirstTask
+	// blocks, so a wide first hop can't unboundedly grow the worker pool's
+	// backlog.
+	defaultMaxPendingTasks = 100000
+)
 
+// tempResult is one unit of traverse work: the frontier reached after
+// chainLevel conditions (and hopCount repeats of the current one), plus a
+// per-path visited set used to prune cycles for variable-length edges.
 type tempResult struct {
 	vertexIds  []int64
 	chainLevel int64
+	// hopCount counts how many times conditionChain[chainLevel] has already
+	// been applied to reach this frontier, so a *min..max* condition can be
+	// iterated in place before chainLevel advances.
+	hopCount int64
+	// visited holds one bitmap per entry in vertexIds (same index), cloned
+	// and extended on every expansion. It is nil when uniqueness is NONE.
+	visited []*roaring64.Bitmap
+
+	// spillKeys is set instead of vertexIds/visited when the frontier that
+	// produced this task exceeded maxFrontierSize and was written to
+	// e.spillFile by spillFrontier; handleTraverseTask hydrates it before
+	// use.
+	spillKeys []int64
 }
 
 type DirType uint8
@@ -30,19 +59,42 @@ const (
 	BOTH
 )
 
+// UniquenessMode controls cycle pruning for variable-length (*min..max*)
+// edges, mirroring Cypher's NODE/RELATIONSHIP/NONE path uniqueness.
+type UniquenessMode uint8
+
+const (
+	UniquenessNone UniquenessMode = iota
+	UniquenessNode
+	UniquenessRelationship
+)
+
 type condition struct {
 	edgeID    int64
 	direction DirType
+
+	// minHops and maxHops bound how many times this condition is applied
+	// before chainLevel advances. A fixed-length edge sets both to 1.
+	minHops int64
+	maxHops int64
+	// uniqueness picks the cycle-pruning rule applied while minHops..maxHops
+	// is walked. When minHops == maxHops == 1 the only edge it can prune is a
+	// NODE-mode self-loop back to the entry vertex.
+	uniqueness UniquenessMode
 }
 
 type TraverseExecutor struct {
 	baseExecutor
 
-	startTS     uint64
-	txn         kv.Transaction
-	snapshot    kv.Snapshot
-	workerWg    *sync.WaitGroup
-	doneErr     error
+	startTS  uint64
+	txn      kv.Transaction
+	snapshot kv.Snapshot
+	// workerWg is a plain (not pointer) sync.WaitGroup so its zero value is
+	// ready to use the moment this executor is constructed - a *sync.WaitGroup
+	// field needs an explicit constructor to set it before Open's first
+	// e.workerWg.Add(1), and this package has none, which used to panic with a
+	// nil-pointer dereference on every query.
+	workerWg    sync.WaitGroup
 	resultTagID int64
 
 	conditionChain []condition
@@ -52,23 +104,57 @@ type TraverseExecutor struct {
 	prepared              bool
 	done                  bool
 
-	mu struct {
-		sync.Mutex
-		childFinish bool
-	}
-	restRow int64
+	// execCtx/cancel are the derived, executor-owned context every worker,
+	// the dispatcher, and the child-fetch goroutine run under; Close cancels
+	// it to unwind the whole pipeline instead of sleeping a fixed duration.
+	execCtx context.Context
+	cancel  context.CancelFunc
+
+	// taskCond guards pendingTasks/childDone and lets
+	// fetchFromChildAndBuildFirstTask block until pendingTasks drops back
+	// under maxPendingTasks, instead of polling.
+	taskCond     *sync.Cond
+	pendingTasks int64
+	childDone    bool
+	finishOnce   sync.Once
+
+	// submitChan is where producers (workers finishing a hop, and the child
+	// fetch goroutine) hand off newly produced frontiers. runDispatcher is
+	// the sole reader and the sole owner of workerInbox, so no producer ever
+	// sends to or closes a channel it doesn't own.
+	submitChan  chan *tempResult
+	workerInbox []chan *tempResult
 
-	workerChan          chan *tempResult
 	fetchFromChildErr   chan error
 	traverseResultVIDCh chan int64
-	closeCh             chan struct{}
-	closeNext           chan struct{}
 
 	tablePlan plannercore.PhysicalPlan
+
+	// mppClient is non-nil when the store can execute conditionChain itself
+	// via dispatchGraphMPPTraverse; TraverseExecutor falls back to driving
+	// every hop in handleTraverseTask when the store doesn't advertise this.
+	mppClient kv.MPPClient
+
+	// maxFrontierSize caps how many vertex IDs a single tempResult may carry
+	// before spillFrontier moves that whole frontier out of memory and onto
+	// spillFile.
+	maxFrontierSize int64
+	// spillSeq generates unique keys for spilled frontiers; see nextSpillKey.
+	spillSeq int64
+	// spillMu guards spillFile/spillIndex, the on-disk (not txn-backed)
+	// holding area spillFrontier/loadSpilledFrontier write scratch frontiers
+	// into. spillFile is opened lazily by spillFrontier and removed by Close.
+	spillMu    sync.Mutex
+	spillFile  *os.File
+	spillIndex map[int64]spillExtent
+
+	// maxPendingTasks caps pendingTasks; see taskCond.
+	maxPendingTasks int64
 }
 
 func (e *TraverseExecutor) Init(p *plannercore.PointGetPlan, startTs uint64) {
 	e.startTS = startTs
+	e.maxFrontierSize = defaultMaxFrontierSize
 }
 
 // Open initializes necessary variables for using this executor.
@@ -114,128 +200,533 @@ func (e *TraverseExecutor) Open(ctx context.Context) error {
 		return err
 	}
 
-	e.startWorkers(ctx)
+	if mppClient, ok := e.graphMPPCapable(); ok && conditionChainMPPEncodable(e.conditionChain) {
+		e.mppClient = mppClient
+	}
+
+	// maxPendingTasks is session-configurable (tidb_max_graph_pending_tasks)
+	// so a session can raise or lower the in-flight task budget to fit its
+	// own query shape instead of being stuck with defaultMaxPendingTasks for
+	// every traverse.
+	e.maxPendingTasks = resolveMaxPendingTasks(e.ctx.GetSessionVars().MaxGraphTraversePendingTasks)
+
+	e.initLifecycle(ctx)
 	return nil
 }
 
-func (e *TraverseExecutor) runNewWorker(ctx context.Context) {
+// resolveMaxPendingTasks falls back to defaultMaxPendingTasks when the
+// session has not set tidb_max_graph_pending_tasks (sessionVar <= 0), the
+// same convention maxFrontierSize's own default follows.
+func resolveMaxPendingTasks(sessionVar int64) int64 {
+	if sessionVar <= 0 {
+		return defaultMaxPendingTasks
+	}
+	return sessionVar
+}
+
+// initLifecycle wires up every field the concurrent traverse pipeline needs -
+// the derived execCtx, taskCond, the two result/error channels, the
+// dispatcher, and the worker pool - split out of Open so it can be exercised
+// directly in a test without needing a live txn/snapshot/child executor.
+func (e *TraverseExecutor) initLifecycle(ctx context.Context) {
+	e.execCtx, e.cancel = context.WithCancel(ctx)
+	e.taskCond = sync.NewCond(&sync.Mutex{})
+	e.fetchFromChildErr = make(chan error, 1)
+	e.traverseResultVIDCh = make(chan int64, workerConcurrency)
+
+	e.startWorkers(e.execCtx)
+	e.workerWg.Add(1)
+	go e.watchCancel(e.execCtx)
+}
+
+// watchCancel wakes any goroutine parked in taskCond.Wait once execCtx is
+// cancelled, so Close never has to wait on a backpressured fetch goroutine
+// that would otherwise sleep until the next decPending.
+func (e *TraverseExecutor) watchCancel(ctx context.Context) {
+	defer e.workerWg.Done()
+	<-ctx.Done()
+	e.taskCond.Broadcast()
+}
+
+// incPending records n new units of in-flight traverse work (a fetched
+// start vertex, a produced child vertex, or an emitted result row).
+func (e *TraverseExecutor) incPending(n int64) {
+	e.taskCond.L.Lock()
+	e.pendingTasks += n
+	e.taskCond.L.Unlock()
+}
+
+// decPending marks n units of in-flight work as fully processed (consumed
+// from traverseResultVIDCh, or expanded into its own children), finishing
+// the executor once the child is exhausted and nothing is left pending.
+func (e *TraverseExecutor) decPending(n int64) {
+	e.taskCond.L.Lock()
+	e.pendingTasks -= n
+	childDone, pending := e.childDone, e.pendingTasks
+	e.taskCond.L.Unlock()
+	e.taskCond.Broadcast()
+	if childDone && pending == 0 {
+		e.finish()
+	}
+}
+
+// markChildDone records that fetchFromChildAndBuildFirstTask will never
+// produce another task, the other half of the condition decPending checks
+// to decide when the executor is finished.
+func (e *TraverseExecutor) markChildDone() {
+	e.taskCond.L.Lock()
+	e.childDone = true
+	pending := e.pendingTasks
+	e.taskCond.L.Unlock()
+	if pending == 0 {
+		e.finish()
+	}
+}
+
+// waitForPendingBudget blocks fetchFromChildAndBuildFirstTask while
+// pendingTasks exceeds maxPendingTasks, so a wide first hop can't
+// unboundedly grow the worker pool's backlog. It returns as soon as ctx is
+// cancelled even if the budget never frees up.
+func (e *TraverseExecutor) waitForPendingBudget(ctx context.Context) {
+	if e.maxPendingTasks <= 0 {
+		return
+	}
+	e.taskCond.L.Lock()
+	for e.pendingTasks > e.maxPendingTasks && ctx.Err() == nil {
+		e.taskCond.Wait()
+	}
+	e.taskCond.L.Unlock()
+}
+
+// finish closes traverseResultVIDCh exactly once, whether triggered by
+// pendingTasks/childDone reaching the natural end of the traversal or by
+// Close tearing the executor down early.
+func (e *TraverseExecutor) finish() {
+	e.finishOnce.Do(func() {
+		e.done = true
+		close(e.traverseResultVIDCh)
+	})
+}
+
+// runDispatcher is the sole owner of workerInbox: the only goroutine that
+// ever sends to or closes a worker's inbox. Every other goroutine that wants
+// to schedule a tempResult sends it to submitChan instead, so nobody can
+// race a send against Close tearing the channels down.
+func (e *TraverseExecutor) runDispatcher(ctx context.Context) {
 	defer func() {
+		for _, inbox := range e.workerInbox {
+			close(inbox)
+		}
 		e.workerWg.Done()
 	}()
 
-	var task *tempResult
-	for ok := true; ok; {
+	next := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case task, ok := <-e.submitChan:
+			if !ok {
+				return
+			}
+			select {
+			case e.workerInbox[next] <- task:
+			case <-ctx.Done():
+				return
+			}
+			next = (next + 1) % len(e.workerInbox)
+		}
+	}
+}
+
+func (e *TraverseExecutor) runNewWorker(ctx context.Context, inbox <-chan *tempResult) {
+	defer e.workerWg.Done()
+
+	for {
 		select {
-		case task, ok = <-e.workerChan:
+		case task, ok := <-inbox:
 			if !ok {
 				return
 			}
-			err := e.handleTraverseTask(ctx, task)
-			if err != nil {
-				e.doneErr = err
+			if err := e.handleTraverseTask(ctx, task); err != nil {
+				e.reportFatalErr(err)
 			}
 		case <-ctx.Done():
 			return
-		case <-e.closeCh:
-			return
 		}
 	}
 }
 
+// reportFatalErr surfaces err to Next the same way fetchFromChildAndBuildFirstTask
+// reports its own errors: by sending it on fetchFromChildErr. The send is
+// best-effort (non-blocking) because more than one worker can hit a KV error
+// around the same time - only the first needs to reach Next, and without the
+// guard a second worker would block forever trying to send into a channel
+// nobody will read from again once Next has already returned the first one.
+func (e *TraverseExecutor) reportFatalErr(err error) {
+	select {
+	case e.fetchFromChildErr <- err:
+	default:
+	}
+}
+
 func (e *TraverseExecutor) startWorkers(ctx context.Context) {
-	e.workerChan = make(chan *tempResult, workerConcurrency)
+	e.submitChan = make(chan *tempResult, workerConcurrency)
+	e.workerInbox = make([]chan *tempResult, workerConcurrency)
+	for i := range e.workerInbox {
+		e.workerInbox[i] = make(chan *tempResult, 1)
+	}
+
+	e.workerWg.Add(1)
+	go e.runDispatcher(ctx)
 
 	for i := 0; i < workerConcurrency; i++ {
 		e.workerWg.Add(1)
-		go e.runNewWorker(ctx)
+		go e.runNewWorker(ctx, e.workerInbox[i])
+	}
+}
+
+// hopDecision says what to do with a vertex that has taken hop edges along a
+// condition: whether it qualifies for emission, whether it should be
+// re-queued at the same chainLevel for another hop, and whether it should
+// advance to the next condition in the chain. hop == 0 is the pass-through
+// case a *0..max* condition needs: a vertex can satisfy such a condition
+// without consuming any edge at all.
+type hopDecision struct {
+	emit    bool
+	again   bool
+	advance bool
+}
+
+// decideHop computes hopDecision for a vertex that has taken hop edges along
+// cond. lastCondition reports whether cond is the last entry in
+// conditionChain - only the last condition's matches are emitted as result
+// rows, every other condition instead advances its matches to the next one.
+func decideHop(cond condition, hop int64, lastCondition bool) hopDecision {
+	inRange := hop >= cond.minHops && hop <= cond.maxHops
+	return hopDecision{
+		emit:    inRange && lastCondition,
+		again:   hop < cond.maxHops,
+		advance: inRange && !lastCondition,
 	}
 }
 
-func (e *TraverseExecutor) handleTraverseTask(ctx context.Context, task *tempResult) error {
+// handleTraverseTask expands every vertex in task one hop along
+// conditionChain[task.chainLevel]. A condition with minHops < maxHops is
+// iterated in place (hopCount increasing, chainLevel unchanged) until
+// maxHops is reached; a result row is only emitted once hopCount+1 falls in
+// [minHops, maxHops] and this is the last condition in the chain. A
+// condition with minHops == 0 additionally lets the incoming vertices pass
+// through untouched the first time it is entered (task.hopCount == 0),
+// since zero edges taken already satisfies it.
+//
+// Every vertex in task.vertexIds was already counted in pendingTasks by
+// whoever produced this task, so each one must be matched by exactly one
+// decPending(1) - including the ones a KV error (or ctx cancellation)
+// leaves unprocessed when it aborts the loop early. Otherwise pendingTasks
+// never reaches zero, finish() never fires, and traverseResultVIDCh never
+// closes, so Next would block forever instead of returning the error.
+func (e *TraverseExecutor) handleTraverseTask(ctx context.Context, task *tempResult) (err error) {
+	if task.spillKeys != nil {
+		hydrated, loadErr := e.loadSpilledFrontier(task)
+		if loadErr != nil {
+			// The spilled frontier's own vertex count is unknown without
+			// decoding it, so pendingTasks can't be corrected here the way
+			// the per-vertex loop below does - but Next must still not hang
+			// waiting on it, so report the error the same way.
+			e.reportFatalErr(loadErr)
+			return loadErr
+		}
+		task = hydrated
+	}
+
 	level := task.chainLevel
-	finish := false
-	var newTask tempResult
-	if level+1 == int64(len(e.conditionChain)) {
-		finish = true
-	}
-	for _, vertexId := range task.vertexIds {
-		var kvRange kv.KeyRange
-		switch e.conditionChain[level].direction {
-		case OUT:
-			kvRange.StartKey = tablecodec.ConstructKeyForGraphTraverse(vertexId, true, e.conditionChain[level].edgeID)
-			kvRange.EndKey = tablecodec.ConstructKeyForGraphTraverse(vertexId, true, e.conditionChain[level].edgeID+1)
-		case IN:
-			kvRange.StartKey = tablecodec.ConstructKeyForGraphTraverse(vertexId, false, e.conditionChain[level].edgeID)
-			kvRange.EndKey = tablecodec.ConstructKeyForGraphTraverse(vertexId, false, e.conditionChain[level].edgeID+1)
-		case BOTH:
-			kvRange.StartKey = tablecodec.ConstructKeyForGraphTraverse(vertexId, true, e.conditionChain[level].edgeID)
-			kvRange.EndKey = tablecodec.ConstructKeyForGraphTraverse(vertexId, true, e.conditionChain[level].edgeID+1)
-			// TODO: cross validate
+	cond := e.conditionChain[level]
+	lastCondition := level+1 == int64(len(e.conditionChain))
+
+	// nextUniqueness governs pruning for whatever enters conditionChain at
+	// level+1, which may be a different uniqueness mode than cond's; it is
+	// only read once nextLevelTask exists, i.e. once !lastCondition.
+	var nextUniqueness UniquenessMode
+	if !lastCondition {
+		nextUniqueness = e.conditionChain[level+1].uniqueness
+	}
+
+	hop := task.hopCount + 1
+	dec := decideHop(cond, hop, lastCondition)
+
+	var sameLevelTask, nextLevelTask *tempResult
+	if dec.again {
+		sameLevelTask = &tempResult{chainLevel: level, hopCount: hop, vertexIds: make([]int64, 0, 100)}
+	}
+	if dec.advance {
+		nextLevelTask = &tempResult{chainLevel: level + 1, hopCount: 0, vertexIds: make([]int64, 0, 100)}
+	}
+	emit := dec.emit
+
+	// unprocessed tracks how many of task.vertexIds still owe their
+	// decPending(1): the defer below settles it however this call exits, so
+	// a KV error (or the zero-hop pass-through failing) partway through
+	// always leaves pendingTasks exactly as if every vertex had finished.
+	unprocessed := len(task.vertexIds)
+	defer func() {
+		if unprocessed > 0 {
+			e.decPending(int64(unprocessed))
 		}
-		iter, err := e.snapshot.Iter(kvRange.StartKey, kvRange.EndKey)
 		if err != nil {
-			return err
+			e.reportFatalErr(err)
 		}
-		if !finish {
-			newTask = tempResult{}
-			newTask.vertexIds = make([]int64, 0, 100)
-			newTask.chainLevel = level + 1
+	}()
+
+	if task.hopCount == 0 {
+		dec0 := decideHop(cond, 0, lastCondition)
+		if dec0.advance && nextLevelTask == nil {
+			nextLevelTask = &tempResult{chainLevel: level + 1, hopCount: 0, vertexIds: make([]int64, 0, 100)}
 		}
-		for iter.Valid() {
-			k := iter.Key()
-			resultID, err := tablecodec.DecodeLastIDOfGraphEdge(k)
-			if err != nil {
-				return err
+		if dec0.emit || dec0.advance {
+			if zeroErr := e.passThroughZeroHop(ctx, task, dec0, nextLevelTask, nextUniqueness); zeroErr != nil {
+				return zeroErr
 			}
+		}
+	}
+
+	for i, vertexID := range task.vertexIds {
+		var visited *roaring64.Bitmap
+		if task.visited != nil {
+			visited = task.visited[i]
+		}
+
+		for _, kvRange := range graphTraverseRanges(vertexID, cond) {
+			iter, iterErr := e.snapshot.Iter(kvRange.StartKey, kvRange.EndKey)
+			if iterErr != nil {
+				return iterErr
+			}
+
+			for iter.Valid() {
+				k := iter.Key()
+				resultID, decodeErr := tablecodec.DecodeLastIDOfGraphEdge(k)
+				if decodeErr != nil {
+					return decodeErr
+				}
 
-			atomic.AddInt64(&e.restRow, 1)
+				if pathVisited(cond.uniqueness, visited, resultID, k) {
+					if nextErr := iter.Next(); nextErr != nil {
+						return nextErr
+					}
+					continue
+				}
+				childVisited := extendVisited(cond.uniqueness, visited, resultID, k)
+
+				if emit {
+					e.incPending(1)
+					select {
+					case <-ctx.Done():
+						return nil
+					case e.traverseResultVIDCh <- resultID:
+					}
+				}
+				if sameLevelTask != nil {
+					e.incPending(1)
+					sameLevelTask.vertexIds = append(sameLevelTask.vertexIds, resultID)
+					sameLevelTask.visited = appendVisited(sameLevelTask.visited, childVisited)
+				}
+				if nextLevelTask != nil {
+					e.incPending(1)
+					nextLevelTask.vertexIds = append(nextLevelTask.vertexIds, resultID)
+					nextLevelTask.visited = appendVisited(nextLevelTask.visited, seedVisited(nextUniqueness, resultID))
+				}
 
-			if finish {
-				select {
-				case <-e.closeCh:
-					return nil
-				default:
-					e.traverseResultVIDCh <- resultID
+				if nextErr := iter.Next(); nextErr != nil {
+					return nextErr
 				}
-			} else {
-				newTask.vertexIds = append(newTask.vertexIds, resultID)
 			}
+		}
 
-			err = iter.Next()
-			if err != nil {
-				return err
+		e.decPending(1)
+		unprocessed--
+	}
+
+	for _, child := range []*tempResult{sameLevelTask, nextLevelTask} {
+		if child == nil || len(child.vertexIds) == 0 {
+			continue
+		}
+		if dispatchErr := e.dispatchChildTask(ctx, child); dispatchErr != nil {
+			return dispatchErr
+		}
+	}
+	return nil
+}
+
+// graphTraverseRanges returns the key range(s) handleTraverseTask must scan
+// to find vertexID's one-hop neighbors along cond. BOTH is undirected, so it
+// scans both the OUT and IN ranges and unions the results, mirroring
+// ShortestPathExecutor.neighbors.
+func graphTraverseRanges(vertexID int64, cond condition) []kv.KeyRange {
+	switch cond.direction {
+	case IN:
+		return []kv.KeyRange{{
+			StartKey: tablecodec.ConstructKeyForGraphTraverse(vertexID, false, cond.edgeID),
+			EndKey:   tablecodec.ConstructKeyForGraphTraverse(vertexID, false, cond.edgeID+1),
+		}}
+	case BOTH:
+		return []kv.KeyRange{
+			{
+				StartKey: tablecodec.ConstructKeyForGraphTraverse(vertexID, true, cond.edgeID),
+				EndKey:   tablecodec.ConstructKeyForGraphTraverse(vertexID, true, cond.edgeID+1),
+			},
+			{
+				StartKey: tablecodec.ConstructKeyForGraphTraverse(vertexID, false, cond.edgeID),
+				EndKey:   tablecodec.ConstructKeyForGraphTraverse(vertexID, false, cond.edgeID+1),
+			},
+		}
+	default: // OUT
+		return []kv.KeyRange{{
+			StartKey: tablecodec.ConstructKeyForGraphTraverse(vertexID, true, cond.edgeID),
+			EndKey:   tablecodec.ConstructKeyForGraphTraverse(vertexID, true, cond.edgeID+1),
+		}}
+	}
+}
+
+// passThroughZeroHop handles a *0..max* condition's zero-edge case: task's
+// own vertices, not any of their neighbors, satisfy cond with hop == 0. It
+// emits them (dec0.emit) and/or forwards them into nextLevelTask
+// (dec0.advance) before any edge is expanded. A forwarded vertex becomes the
+// entry point of conditionChain[level+1] without having taken one of its
+// hops, so its visited state is reseeded from nextUniqueness - that
+// condition's own uniqueness mode - exactly as if it had just arrived from
+// the child executor, not carried over from the condition it is leaving.
+func (e *TraverseExecutor) passThroughZeroHop(ctx context.Context, task *tempResult, dec0 hopDecision, nextLevelTask *tempResult, nextUniqueness UniquenessMode) error {
+	for _, vertexID := range task.vertexIds {
+		if dec0.emit {
+			e.incPending(1)
+			select {
+			case <-ctx.Done():
+				return nil
+			case e.traverseResultVIDCh <- vertexID:
 			}
 		}
-		if !finish {
-			e.workerChan <- &newTask
+		if dec0.advance {
+			e.incPending(1)
+			nextLevelTask.vertexIds = append(nextLevelTask.vertexIds, vertexID)
+			nextLevelTask.visited = appendVisited(nextLevelTask.visited, seedVisited(nextUniqueness, vertexID))
 		}
-		e.mu.Lock()
-		atomic.AddInt64(&e.restRow, -1)
-		if e.mu.childFinish && atomic.LoadInt64(&e.restRow) == 0 {
-			e.done = true
-			close(e.closeNext)
-			e.mu.Unlock()
-			return nil
+	}
+	return nil
+}
+
+// dispatchChildTask hands child to runDispatcher via submitChan, spilling
+// its frontier to spillFile on disk first when it has grown past
+// maxFrontierSize so a wide hop can't unboundedly grow the worker pool's
+// backlog.
+func (e *TraverseExecutor) dispatchChildTask(ctx context.Context, child *tempResult) error {
+	if e.maxFrontierSize > 0 && int64(len(child.vertexIds)) > e.maxFrontierSize {
+		spilled, err := e.spillFrontier(child)
+		if err != nil {
+			return err
 		}
-		e.mu.Unlock()
+		child = spilled
+	}
+	select {
+	case e.submitChan <- child:
+	case <-ctx.Done():
 	}
 	return nil
 }
 
+// pathVisited reports whether resultID (or, for RELATIONSHIP uniqueness, the
+// edge k was reached through) is already a member of visited and must be
+// pruned to avoid a cycle. It is always false for UniquenessNone.
+func pathVisited(mode UniquenessMode, visited *roaring64.Bitmap, resultID int64, k kv.Key) bool {
+	if visited == nil || mode == UniquenessNone {
+		return false
+	}
+	switch mode {
+	case UniquenessNode:
+		return visited.Contains(uint64(resultID))
+	case UniquenessRelationship:
+		return visited.Contains(edgeKeyHash(k))
+	default:
+		return false
+	}
+}
+
+// extendVisited clones visited (or creates an empty bitmap on the first hop)
+// and records resultID/k's edge as seen on this path. It returns nil for
+// UniquenessNone, since no pruning state needs to be carried forward.
+func extendVisited(mode UniquenessMode, visited *roaring64.Bitmap, resultID int64, k kv.Key) *roaring64.Bitmap {
+	if mode == UniquenessNone {
+		return nil
+	}
+	var next *roaring64.Bitmap
+	if visited == nil {
+		next = roaring64.New()
+	} else {
+		next = visited.Clone()
+	}
+	switch mode {
+	case UniquenessNode:
+		next.Add(uint64(resultID))
+	case UniquenessRelationship:
+		next.Add(edgeKeyHash(k))
+	}
+	return next
+}
+
+// seedVisited returns the visited bitmap a vertex must carry the moment it
+// becomes the entry point of a condition in mode, before that condition has
+// taken any hop of its own: nil for NONE, a bitmap already containing vid for
+// NODE (the entry vertex counts as visited on its own path), and a fresh
+// empty bitmap for RELATIONSHIP (no edge has been traversed under the new
+// condition yet). Used both to seed conditionChain[0] from the child
+// executor's rows and to reseed a frontier advancing into conditionChain's
+// next condition, since that condition's uniqueness mode - not the one just
+// traversed - is what governs pruning from here on.
+func seedVisited(mode UniquenessMode, vid int64) *roaring64.Bitmap {
+	switch mode {
+	case UniquenessNode:
+		bm := roaring64.New()
+		bm.Add(uint64(vid))
+		return bm
+	case UniquenessRelationship:
+		return roaring64.New()
+	default:
+		return nil
+	}
+}
+
+func appendVisited(visited []*roaring64.Bitmap, child *roaring64.Bitmap) []*roaring64.Bitmap {
+	if child == nil {
+		return nil
+	}
+	return append(visited, child)
+}
+
+func edgeKeyHash(k kv.Key) uint64 {
+	h := fnv.New64a()
+	_, _ = h.Write(k)
+	return h.Sum64()
+}
+
 func (e *TraverseExecutor) fetchFromChildAndBuildFirstTask(ctx context.Context) {
 	defer func() {
 		e.workerWg.Done()
-		e.mu.Lock()
-		e.mu.childFinish = true
-		e.mu.Unlock()
+		e.markChildDone()
 	}()
 
 	chk := newFirstChunk(e.children[0])
 
+	startUniqueness := UniquenessNone
+	if len(e.conditionChain) > 0 {
+		startUniqueness = e.conditionChain[0].uniqueness
+	}
+
 	for {
-		newTask := tempResult{}
-		newTask.chainLevel = 0
-		newTask.vertexIds = make([]int64, 0, 100)
+		e.waitForPendingBudget(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		newTask := tempResult{chainLevel: 0, vertexIds: make([]int64, 0, 100)}
 		chk.Reset()
 		if err := Next(ctx, e.children[0], chk); err != nil {
 			e.fetchFromChildErr <- err
@@ -247,26 +738,61 @@ func (e *TraverseExecutor) fetchFromChildAndBuildFirstTask(ctx context.Context)
 		for i := 0; i < chk.NumRows(); i++ {
 			vid := chk.GetRow(i).GetInt64(int(e.vertexIdOffsetInChild))
 			newTask.vertexIds = append(newTask.vertexIds, vid)
+			newTask.visited = appendVisited(newTask.visited, seedVisited(startUniqueness, vid))
 		}
-		atomic.AddInt64(&e.restRow, int64(chk.NumRows()))
-		e.workerChan <- &newTask
+		e.incPending(int64(chk.NumRows()))
+
+		if e.mppClient != nil {
+			if err := e.dispatchFirstTaskToMPP(ctx, newTask.vertexIds); err != nil {
+				e.fetchFromChildErr <- err
+				return
+			}
+			e.decPending(int64(len(newTask.vertexIds)))
+			continue
+		}
+		if err := e.dispatchChildTask(ctx, &newTask); err != nil {
+			e.fetchFromChildErr <- err
+			return
+		}
+	}
+}
+
+// dispatchFirstTaskToMPP builds and dispatches a coprocessor/MPP task for one
+// batch of start vertices fetched from the child executor. It is the MPP
+// counterpart of sending a tempResult to submitChan: the whole conditionChain
+// is walked server-side and only the terminal vertexId set comes back.
+func (e *TraverseExecutor) dispatchFirstTaskToMPP(ctx context.Context, startIDs []int64) error {
+	assignments, err := e.buildGraphMPPTasks(ctx, e.mppClient, startIDs)
+	if err != nil {
+		return err
 	}
+	if len(assignments) == 0 {
+		return nil
+	}
+	return e.dispatchGraphMPPTraverse(ctx, e.mppClient, assignments)
 }
 
 func (e *TraverseExecutor) ConstructResultRow(ctx context.Context, vid int64, req *chunk.Chunk) error {
-	key := tablecodec.EncodeGraphTag(vid, e.resultTagID)
-	value, err := e.snapshot.Get(ctx, key)
+	return constructGraphResultRow(ctx, e.snapshot, e.ChunkDecoder, e.resultTagID, vid, req)
+}
+
+// constructGraphResultRow decodes vertex vid's tagged row into req. It is
+// shared by every graph executor (TraverseExecutor, ShortestPathExecutor, ...)
+// that needs to turn a bare vertexId into a result row.
+func constructGraphResultRow(ctx context.Context, snapshot kv.Snapshot, decoder *rowcodec.ChunkDecoder, resultTagID, vid int64, req *chunk.Chunk) error {
+	key := tablecodec.EncodeGraphTag(vid, resultTagID)
+	value, err := snapshot.Get(ctx, key)
 	if err != nil {
 		return err
 	}
 
-	return e.ChunkDecoder.DecodeToChunk(value, kv.IntHandle(vid), req)
+	return decoder.DecodeToChunk(value, kv.IntHandle(vid), req)
 }
 
 func (e *TraverseExecutor) Next(ctx context.Context, req *chunk.Chunk) error {
 	if !e.prepared {
 		e.workerWg.Add(1)
-		go e.fetchFromChildAndBuildFirstTask(ctx)
+		go e.fetchFromChildAndBuildFirstTask(e.execCtx)
 		e.prepared = true
 	}
 
@@ -279,42 +805,40 @@ func (e *TraverseExecutor) Next(ctx context.Context, req *chunk.Chunk) error {
 		select {
 		case err := <-e.fetchFromChildErr:
 			return err
-		case <-e.closeNext:
-			return nil
 		case vid, ok := <-e.traverseResultVIDCh:
 			if !ok {
 				return nil
 			}
-			err := e.ConstructResultRow(ctx, vid, req)
-			if err != nil {
+			if err := e.ConstructResultRow(ctx, vid, req); err != nil {
 				return err
 			}
+			e.decPending(1)
 			if req.IsFull() {
 				return nil
 			}
-			e.mu.Lock()
-			atomic.AddInt64(&e.restRow, -1)
-			if e.mu.childFinish && atomic.LoadInt64(&e.restRow) == 0 {
-				e.mu.Unlock()
-				e.done = true
-				return nil
-			}
-			e.mu.Unlock()
 		}
 	}
 }
 
+// Close cancels execCtx so every worker, the dispatcher, and the child-fetch
+// goroutine unwind on their own, waits for them to actually exit, then
+// drains whatever is left buffered in traverseResultVIDCh and closes it.
+// There is nothing left to race by the time workerWg.Wait returns, so no
+// sleep is needed to make the teardown safe.
 func (e *TraverseExecutor) Close() error {
-	close(e.closeCh)
-	close(e.workerChan)
-	go func() {
-		for range e.traverseResultVIDCh {
-		}
-	}()
-
-	time.Sleep(100 * time.Millisecond)
-
-	close(e.traverseResultVIDCh)
+	e.cancel()
 	e.workerWg.Wait()
-	return nil
+	e.closeSpillFile()
+
+	for {
+		select {
+		case _, ok := <-e.traverseResultVIDCh:
+			if !ok {
+				return nil
+			}
+		default:
+			e.finish()
+			return nil
+		}
+	}
 }
@@ -0,0 +1,210 @@
+// This file pushes conditionChain traversal down to region-local coprocessor
+// tasks instead of driving every hop from TiDB. Scope note: it only covers
+// dispatching the region tasks planned for each start vertex and draining the
+// terminal vertexIds each task returns - the region-to-region frontier shuffle (an
+// ExchangeSender/ExchangeReceiver pair forwarding a frontier that crosses
+// into a neighboring region mid-chain) is not implemented here; see
+// dispatchGraphMPPTraverse's comment for why. Today every conditionChain hop
+// is still walked to completion inside whichever single region owns the
+// start vertex, so conditionChainMPPEncodable refuses every chain longer
+// than one condition - TraverseExecutor falls back to handleTraverseTask for
+// those instead of silently truncating at a region boundary.
+package executor
+
+import (
+	"context"
+	"encoding/binary"
+
+	"github.com/pingcap/errors"
+	"github.com/pingcap/tidb/kv"
+)
+
+// graphMPPCapable reports whether the current store advertises MPP-style
+// coprocessor execution, the same kv.MPPClient assertion TiDB already uses to
+// decide whether a query fragment can be pushed down to TiFlash.
+func (e *TraverseExecutor) graphMPPCapable() (kv.MPPClient, bool) {
+	mppClient, ok := e.ctx.GetStore().(kv.MPPClient)
+	if !ok || !e.ctx.GetSessionVars().AllowMPPExecution {
+		return nil, false
+	}
+	return mppClient, true
+}
+
+// conditionChainMPPEncodable reports whether chain can be both represented
+// by encodeGraphConditionChain's wire format and executed correctly by the
+// MPP path. A variable-length (minHops != 1 || maxHops != 1) or
+// uniqueness-bearing condition would silently be flattened into a fixed
+// single hop on the wire, so those are refused until the wire format grows
+// fields for them. A chain of more than one condition is refused too: hop 2+
+// routinely crosses into a region other than the start vertex's, and the
+// region-to-region frontier shuffle dispatchGraphMPPTraverse's doc comment
+// describes is not implemented, so today every hop is walked to completion
+// inside whichever single region owns the start vertex - silently dropping
+// any vertex whose next hop left that region. Refuse multi-condition chains
+// here until that shuffle exists, instead of returning a truncated result
+// set.
+func conditionChainMPPEncodable(chain []condition) bool {
+	if len(chain) > 1 {
+		return false
+	}
+	for _, c := range chain {
+		if c.minHops != 1 || c.maxHops != 1 || c.uniqueness != UniquenessNone {
+			return false
+		}
+	}
+	return true
+}
+
+// graphMPPTaskAssignment pairs a region task with exactly the start vertex
+// IDs whose key range it was planned for, so dispatchGraphMPPTraverse only
+// ships the start vertices a task actually owns instead of the whole
+// traversal's start set to every task it dispatches to.
+type graphMPPTaskAssignment struct {
+	task     kv.MPPTaskMeta
+	startIDs []int64
+}
+
+// buildGraphMPPTasks asks the store to plan the region task(s) that own each
+// start vertex's edge-index key range, one vertex at a time: kv.MPPClient has
+// no API here for recovering which of a batch of ranges a returned task came
+// from, so planning them together would leave no way to tell which startIDs
+// any one task is actually allowed to see, and dispatchGraphMPPTraverse would
+// have to fall back to sending every startID to every task.
+func (e *TraverseExecutor) buildGraphMPPTasks(ctx context.Context, mppClient kv.MPPClient, startIDs []int64) ([]graphMPPTaskAssignment, error) {
+	if len(e.conditionChain) == 0 {
+		return nil, nil
+	}
+
+	assignments := make([]graphMPPTaskAssignment, 0, len(startIDs))
+	for _, vid := range startIDs {
+		req := &kv.MPPBuildTasksRequest{
+			KeyRanges: graphTraverseKeyRanges([]int64{vid}, e.conditionChain),
+		}
+		tasks, err := mppClient.ConstructMPPTasks(ctx, req)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		for _, task := range tasks {
+			assignments = append(assignments, graphMPPTaskAssignment{task: task, startIDs: []int64{vid}})
+		}
+	}
+	return assignments, nil
+}
+
+// graphTraverseKeyRanges computes the edge-index prefixes the first hop of
+// conditionChain will read for startIDs, which is all buildGraphMPPTasks
+// needs to learn which regions should receive a task.
+func graphTraverseKeyRanges(startIDs []int64, chain []condition) []kv.KeyRange {
+	if len(chain) == 0 {
+		return nil
+	}
+	first := chain[0]
+	ranges := make([]kv.KeyRange, 0, len(startIDs))
+	for _, vid := range startIDs {
+		ranges = append(ranges, graphTraverseRanges(vid, first)...)
+	}
+	return ranges
+}
+
+// dispatchGraphMPPTraverse ships the full conditionChain to each assignment's
+// task along with only the start vertex IDs it owns, then streams the
+// terminal vertexId set back into traverseResultVIDCh.
+//
+// Each task's region-local executor is expected to walk conditionChain
+// against the vertices it owns and to shuffle any intermediate frontier that
+// crosses into a neighboring region's key range to the task covering that
+// region before continuing the next hop. That region-to-region shuffle runs
+// entirely inside the storage layer's coprocessor executor and is not
+// implemented in this package; from TiDB's side, dispatchGraphMPPTraverse
+// only needs to start every task once and drain whatever vertexIds come
+// back, exactly like the single coprocessor request TraverseExecutor would
+// otherwise make per hop. conditionChainMPPEncodable restricts this path to
+// single-condition chains so that assumption can never be violated - a
+// multi-hop chain whose second hop crosses into another region would
+// otherwise have no way to get there and would silently vanish from the
+// result set.
+func (e *TraverseExecutor) dispatchGraphMPPTraverse(ctx context.Context, mppClient kv.MPPClient, assignments []graphMPPTaskAssignment) error {
+	conditionChainBytes := encodeGraphConditionChain(e.conditionChain)
+
+	dispatchErrCh := make(chan error, len(assignments))
+	tasks := make([]kv.MPPTaskMeta, len(assignments))
+	for i, a := range assignments {
+		tasks[i] = a.task
+		req := &kv.MPPDispatchRequest{
+			Task:           a.task,
+			ConditionChain: conditionChainBytes,
+			StartVertexIDs: a.startIDs,
+		}
+		go func(req *kv.MPPDispatchRequest) {
+			dispatchErrCh <- mppClient.DispatchMPPTask(ctx, req)
+		}(req)
+	}
+
+	for range assignments {
+		select {
+		case err := <-dispatchErrCh:
+			if err != nil {
+				return errors.Trace(err)
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	conn, err := mppClient.EstablishMPPConns(ctx, tasks)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer conn.Close()
+
+	return e.drainMPPResults(ctx, conn)
+}
+
+// mppResultConn is the minimal interface dispatchGraphMPPTraverse needs from
+// the connection kv.MPPClient.EstablishMPPConns returns. Narrowing it out of
+// kv.MPPClient lets drainMPPResults - the half of this file that had the
+// pendingTasks accounting bug - be driven directly by a test, instead of only
+// through a hand-rolled stand-in for the channel send it performs.
+type mppResultConn interface {
+	Recv() (vid int64, ok bool, err error)
+}
+
+// drainMPPResults reads every vertexId conn has left and forwards it to
+// traverseResultVIDCh, incrementing pendingTasks first. Every vertexId read
+// off the wire is exactly as much in-flight traverse work as one produced by
+// the local handleTraverseTask path, so it must be counted the same way
+// before it reaches Next - otherwise Next's decPending(1) runs unmatched by
+// any incPending and pendingTasks drifts negative, permanently unblocking
+// waitForPendingBudget and corrupting the finish() accounting for every query
+// that follows this one on the same executor.
+func (e *TraverseExecutor) drainMPPResults(ctx context.Context, conn mppResultConn) error {
+	for {
+		vid, ok, err := conn.Recv()
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if !ok {
+			return nil
+		}
+		e.incPending(1)
+		select {
+		case <-ctx.Done():
+			return nil
+		case e.traverseResultVIDCh <- vid:
+		}
+	}
+}
+
+// encodeGraphConditionChain serializes conditionChain so it can be shipped in
+// a single coprocessor/MPP request; the region-local executor decodes it and
+// walks the chain itself rather than waiting for TiDB to drive each hop.
+func encodeGraphConditionChain(chain []condition) []byte {
+	buf := make([]byte, 0, len(chain)*9)
+	for _, c := range chain {
+		var edgeIDBuf [8]byte
+		binary.LittleEndian.PutUint64(edgeIDBuf[:], uint64(c.edgeID))
+		buf = append(buf, edgeIDBuf[:]...)
+		buf = append(buf, byte(c.direction))
+	}
+	return buf
+}
@@ -0,0 +1,108 @@
+package executor
+
+import (
+	"context"
+	"sync"
+	"testing"
+)
+
+func newMPPTestTraverseExecutor() *TraverseExecutor {
+	e := &TraverseExecutor{}
+	e.taskCond = sync.NewCond(&sync.Mutex{})
+	e.traverseResultVIDCh = make(chan int64, 8)
+	return e
+}
+
+func TestEncodeGraphConditionChain(t *testing.T) {
+	chain := []condition{
+		{edgeID: 1, direction: OUT},
+		{edgeID: 300, direction: IN},
+	}
+	got := encodeGraphConditionChain(chain)
+	if len(got) != len(chain)*9 {
+		t.Fatalf("expected %d bytes, got %d", len(chain)*9, len(got))
+	}
+	if got[8] != byte(OUT) {
+		t.Fatalf("direction byte for first condition = %d, want %d", got[8], byte(OUT))
+	}
+	if got[17] != byte(IN) {
+		t.Fatalf("direction byte for second condition = %d, want %d", got[17], byte(IN))
+	}
+}
+
+func TestConditionChainMPPEncodable(t *testing.T) {
+	fixedHop := condition{edgeID: 1, direction: OUT, minHops: 1, maxHops: 1}
+
+	if !conditionChainMPPEncodable([]condition{fixedHop}) {
+		t.Fatalf("a single fixed-length condition must be MPP-encodable")
+	}
+	if conditionChainMPPEncodable([]condition{fixedHop, fixedHop}) {
+		t.Fatalf("a multi-condition chain must be refused: the region-to-region frontier shuffle hop 2+ needs isn't implemented yet, so MPP would silently truncate results at a region boundary")
+	}
+	variableLength := condition{edgeID: 1, direction: OUT, minHops: 1, maxHops: 2}
+	if conditionChainMPPEncodable([]condition{variableLength}) {
+		t.Fatalf("a variable-length condition must be refused: the wire format has no field for minHops/maxHops")
+	}
+	uniquenessBearing := condition{edgeID: 1, direction: OUT, minHops: 1, maxHops: 1, uniqueness: UniquenessNode}
+	if conditionChainMPPEncodable([]condition{uniquenessBearing}) {
+		t.Fatalf("a uniqueness-bearing condition must be refused: the wire format has no field for it")
+	}
+}
+
+func TestGraphTraverseKeyRangesEmptyChain(t *testing.T) {
+	if ranges := graphTraverseKeyRanges([]int64{1, 2, 3}, nil); ranges != nil {
+		t.Fatalf("expected nil ranges for an empty condition chain, got %v", ranges)
+	}
+}
+
+func TestGraphTraverseKeyRangesOnePerStartID(t *testing.T) {
+	startIDs := []int64{1, 2, 3}
+	chain := []condition{{edgeID: 5, direction: OUT}}
+	ranges := graphTraverseKeyRanges(startIDs, chain)
+	if len(ranges) != len(startIDs) {
+		t.Fatalf("expected %d ranges, got %d", len(startIDs), len(ranges))
+	}
+}
+
+// fakeMPPResultConn is a hand-rolled mppResultConn that replays a fixed list
+// of vertexIds and then reports EOF via ok=false, letting drainMPPResults -
+// the real production method - be driven directly in a test instead of
+// through a stand-in for the channel send it performs.
+type fakeMPPResultConn struct {
+	vids []int64
+	next int
+}
+
+func (c *fakeMPPResultConn) Recv() (int64, bool, error) {
+	if c.next >= len(c.vids) {
+		return 0, false, nil
+	}
+	vid := c.vids[c.next]
+	c.next++
+	return vid, true, nil
+}
+
+// TestDrainMPPResultsIncPendingBalance guards the fix for the pendingTasks
+// imbalance a reviewer found: every vertexId streamed back from conn.Recv
+// must be counted in pendingTasks before it reaches traverseResultVIDCh, the
+// same way handleTraverseTask's local emit path does, or Next's
+// unconditional decPending(1) drives pendingTasks negative.
+func TestDrainMPPResultsIncPendingBalance(t *testing.T) {
+	e := newMPPTestTraverseExecutor()
+	conn := &fakeMPPResultConn{vids: []int64{42, 43}}
+
+	if err := e.drainMPPResults(context.Background(), conn); err != nil {
+		t.Fatalf("drainMPPResults: %v", err)
+	}
+	<-e.traverseResultVIDCh
+	<-e.traverseResultVIDCh
+	e.decPending(1)
+	e.decPending(1)
+
+	e.taskCond.L.Lock()
+	pending := e.pendingTasks
+	e.taskCond.L.Unlock()
+	if pending != 0 {
+		t.Fatalf("pendingTasks = %d, want 0 after drainMPPResults' inc is matched by decPending", pending)
+	}
+}
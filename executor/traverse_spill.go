@@ -0,0 +1,201 @@
+package executor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync/atomic"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/pingcap/errors"
+)
+
+// spillExtent locates one spilled frontier's encoded bytes within spillFile.
+type spillExtent struct {
+	offset int64
+	length int64
+}
+
+// spillFrontier moves a tempResult's vertexIds/visited out of memory and onto
+// e.spillFile, returning a lightweight task that carries only the key(s)
+// needed to hydrate it back. It is used when a frontier grows past
+// maxFrontierSize so a wide hop can't unboundedly grow submitChan's backlog
+// or, before this, an in-process map with no size cap of its own.
+//
+// spillFile is a temp file local to this executor, not the user's txn
+// mem-buffer: a spilled frontier is scratch state for this query alone, and
+// writing it through e.txn would make it part of the transaction's write
+// set, so an abandoned spill (e.g. Close cancelling execCtx before a spilled
+// frontier is hydrated back) would risk committing orphaned rows into the
+// user's table data.
+func (e *TraverseExecutor) spillFrontier(task *tempResult) (*tempResult, error) {
+	value, err := encodeSpilledFrontier(task)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	key := e.nextSpillKey()
+
+	e.spillMu.Lock()
+	if e.spillFile == nil {
+		f, err := os.CreateTemp("", "tidb-graph-traverse-spill-*")
+		if err != nil {
+			e.spillMu.Unlock()
+			return nil, errors.Trace(err)
+		}
+		e.spillFile = f
+		e.spillIndex = make(map[int64]spillExtent)
+	}
+	offset, err := e.spillFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		e.spillMu.Unlock()
+		return nil, errors.Trace(err)
+	}
+	if _, err := e.spillFile.Write(value); err != nil {
+		e.spillMu.Unlock()
+		return nil, errors.Trace(err)
+	}
+	e.spillIndex[key] = spillExtent{offset: offset, length: int64(len(value))}
+	e.spillMu.Unlock()
+
+	return &tempResult{
+		chainLevel: task.chainLevel,
+		hopCount:   task.hopCount,
+		spillKeys:  []int64{key},
+	}, nil
+}
+
+// loadSpilledFrontier reads back every key in task.spillKeys from
+// e.spillFile and stitches the decoded frontiers into a regular, in-memory
+// tempResult.
+func (e *TraverseExecutor) loadSpilledFrontier(task *tempResult) (*tempResult, error) {
+	hydrated := &tempResult{chainLevel: task.chainLevel, hopCount: task.hopCount}
+	for _, key := range task.spillKeys {
+		value, err := e.readSpillExtent(key)
+		if err != nil {
+			return nil, err
+		}
+
+		ids, visited, err := decodeSpilledFrontier(value)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		hydrated.vertexIds = append(hydrated.vertexIds, ids...)
+		hydrated.visited = append(hydrated.visited, visited...)
+	}
+	return hydrated, nil
+}
+
+// readSpillExtent reads the bytes spillFrontier wrote for key and drops key
+// from spillIndex. The bytes themselves stay in spillFile, which is only
+// appended to, so its disk usage grows with every frontier spilled by this
+// query until closeSpillFile removes it.
+func (e *TraverseExecutor) readSpillExtent(key int64) ([]byte, error) {
+	e.spillMu.Lock()
+	defer e.spillMu.Unlock()
+
+	extent, ok := e.spillIndex[key]
+	if !ok {
+		return nil, errors.Errorf("graph traverse: spilled frontier %d not found", key)
+	}
+	delete(e.spillIndex, key)
+
+	value := make([]byte, extent.length)
+	if _, err := e.spillFile.ReadAt(value, extent.offset); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return value, nil
+}
+
+// closeSpillFile releases spillFile's descriptor and removes it from disk.
+// It is called from Close; an executor that never spilled leaves spillFile
+// nil and has nothing to clean up.
+func (e *TraverseExecutor) closeSpillFile() {
+	e.spillMu.Lock()
+	defer e.spillMu.Unlock()
+	if e.spillFile == nil {
+		return
+	}
+	name := e.spillFile.Name()
+	e.spillFile.Close()
+	os.Remove(name)
+	e.spillFile = nil
+	e.spillIndex = nil
+}
+
+// nextSpillKey returns a key unique to this executor's spillFile.
+func (e *TraverseExecutor) nextSpillKey() int64 {
+	return atomic.AddInt64(&e.spillSeq, 1)
+}
+
+// encodeSpilledFrontier serializes vertexIds and (when present) their
+// per-path visited bitmaps so they can be round-tripped through spillFile by
+// spillFrontier/loadSpilledFrontier.
+func encodeSpilledFrontier(task *tempResult) ([]byte, error) {
+	var buf bytes.Buffer
+	hasVisited := task.visited != nil
+	if err := binary.Write(&buf, binary.LittleEndian, int64(len(task.vertexIds))); err != nil {
+		return nil, err
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, hasVisited); err != nil {
+		return nil, err
+	}
+	for i, vid := range task.vertexIds {
+		if err := binary.Write(&buf, binary.LittleEndian, vid); err != nil {
+			return nil, err
+		}
+		if !hasVisited {
+			continue
+		}
+		bmBytes, err := task.visited[i].ToBytes()
+		if err != nil {
+			return nil, err
+		}
+		if err := binary.Write(&buf, binary.LittleEndian, int64(len(bmBytes))); err != nil {
+			return nil, err
+		}
+		buf.Write(bmBytes)
+	}
+	return buf.Bytes(), nil
+}
+
+func decodeSpilledFrontier(value []byte) ([]int64, []*roaring64.Bitmap, error) {
+	buf := bytes.NewReader(value)
+	var n int64
+	if err := binary.Read(buf, binary.LittleEndian, &n); err != nil {
+		return nil, nil, err
+	}
+	var hasVisited bool
+	if err := binary.Read(buf, binary.LittleEndian, &hasVisited); err != nil {
+		return nil, nil, err
+	}
+	ids := make([]int64, 0, n)
+	var visited []*roaring64.Bitmap
+	if hasVisited {
+		visited = make([]*roaring64.Bitmap, 0, n)
+	}
+	for i := int64(0); i < n; i++ {
+		var vid int64
+		if err := binary.Read(buf, binary.LittleEndian, &vid); err != nil {
+			return nil, nil, err
+		}
+		ids = append(ids, vid)
+		if !hasVisited {
+			continue
+		}
+		var bmLen int64
+		if err := binary.Read(buf, binary.LittleEndian, &bmLen); err != nil {
+			return nil, nil, err
+		}
+		bmBytes := make([]byte, bmLen)
+		if _, err := buf.Read(bmBytes); err != nil {
+			return nil, nil, err
+		}
+		bm := roaring64.New()
+		if _, err := bm.FromBuffer(bmBytes); err != nil {
+			return nil, nil, err
+		}
+		visited = append(visited, bm)
+	}
+	return ids, visited, nil
+}
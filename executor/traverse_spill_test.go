@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+)
+
+func TestEncodeDecodeSpilledFrontierNoVisited(t *testing.T) {
+	task := &tempResult{vertexIds: []int64{1, 2, 3}}
+	data, err := encodeSpilledFrontier(task)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	ids, visited, err := decodeSpilledFrontier(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if visited != nil {
+		t.Fatalf("expected nil visited, got %v", visited)
+	}
+	if len(ids) != len(task.vertexIds) {
+		t.Fatalf("expected %d ids, got %d", len(task.vertexIds), len(ids))
+	}
+	for i, id := range ids {
+		if id != task.vertexIds[i] {
+			t.Fatalf("id[%d] = %d, want %d", i, id, task.vertexIds[i])
+		}
+	}
+}
+
+func TestEncodeDecodeSpilledFrontierWithVisited(t *testing.T) {
+	bm1 := roaring64.New()
+	bm1.Add(10)
+	bm2 := roaring64.New()
+	bm2.Add(20)
+	bm2.Add(21)
+
+	task := &tempResult{vertexIds: []int64{10, 20}, visited: []*roaring64.Bitmap{bm1, bm2}}
+	data, err := encodeSpilledFrontier(task)
+	if err != nil {
+		t.Fatalf("encode: %v", err)
+	}
+	_, visited, err := decodeSpilledFrontier(data)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(visited) != 2 {
+		t.Fatalf("expected 2 visited bitmaps, got %d", len(visited))
+	}
+	if !visited[0].Equals(bm1) || !visited[1].Equals(bm2) {
+		t.Fatalf("decoded bitmaps do not match originals")
+	}
+}
+
+// TestSpillFrontierDoesNotTouchTxn guards the fix for a reviewer-flagged
+// issue: spillFrontier/loadSpilledFrontier must work against a bare
+// TraverseExecutor with no live txn, proving the spilled frontier is no
+// longer part of the transaction's write set.
+func TestSpillFrontierDoesNotTouchTxn(t *testing.T) {
+	e := &TraverseExecutor{}
+	task := &tempResult{vertexIds: []int64{1, 2, 3}}
+
+	spilled, err := e.spillFrontier(task)
+	if err != nil {
+		t.Fatalf("spillFrontier: %v", err)
+	}
+	if spilled.vertexIds != nil {
+		t.Fatalf("spilled task should carry no vertexIds directly")
+	}
+	if len(spilled.spillKeys) != 1 {
+		t.Fatalf("expected 1 spill key, got %d", len(spilled.spillKeys))
+	}
+
+	hydrated, err := e.loadSpilledFrontier(spilled)
+	if err != nil {
+		t.Fatalf("loadSpilledFrontier: %v", err)
+	}
+	if len(hydrated.vertexIds) != len(task.vertexIds) {
+		t.Fatalf("expected %d hydrated ids, got %d", len(task.vertexIds), len(hydrated.vertexIds))
+	}
+
+	e.spillMu.Lock()
+	if len(e.spillIndex) != 0 {
+		e.spillMu.Unlock()
+		t.Fatalf("expected loadSpilledFrontier to delete the key, spillIndex still has %d entries", len(e.spillIndex))
+	}
+	e.spillMu.Unlock()
+
+	e.closeSpillFile()
+}
+
+// TestSpillFrontierIsDiskBackedNotUnboundedMemory guards the fix for a
+// reviewer-flagged issue: spillFrontier must write frontier bytes to
+// spillFile on disk, not accumulate them in an unbounded in-process map, so
+// a wide hop that spills many frontiers grows disk usage rather than the
+// worker pool's memory footprint.
+func TestSpillFrontierIsDiskBackedNotUnboundedMemory(t *testing.T) {
+	e := &TraverseExecutor{}
+	defer e.closeSpillFile()
+
+	if _, err := e.spillFrontier(&tempResult{vertexIds: []int64{1, 2, 3}}); err != nil {
+		t.Fatalf("spillFrontier: %v", err)
+	}
+
+	e.spillMu.Lock()
+	f := e.spillFile
+	e.spillMu.Unlock()
+	if f == nil {
+		t.Fatalf("expected spillFrontier to open a backing file")
+	}
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatalf("stat spill file: %v", err)
+	}
+	if info.Size() == 0 {
+		t.Fatalf("expected spilled bytes to be written to disk, spill file is empty")
+	}
+}
@@ -0,0 +1,259 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RoaringBitmap/roaring/roaring64"
+	"github.com/pingcap/tidb/kv"
+	"github.com/pingcap/tidb/util/chunk"
+)
+
+func newTestTraverseExecutor() *TraverseExecutor {
+	e := &TraverseExecutor{}
+	e.taskCond = sync.NewCond(&sync.Mutex{})
+	e.traverseResultVIDCh = make(chan int64, 4)
+	return e
+}
+
+func TestFinishesOnceChildDoneAndPendingDrained(t *testing.T) {
+	e := newTestTraverseExecutor()
+	e.incPending(3)
+	e.decPending(1)
+	e.decPending(1)
+
+	select {
+	case <-e.traverseResultVIDCh:
+		t.Fatalf("channel closed too early, pendingTasks should still be 1")
+	default:
+	}
+
+	e.markChildDone()
+	select {
+	case <-e.traverseResultVIDCh:
+		t.Fatalf("channel closed while pendingTasks > 0")
+	default:
+	}
+
+	e.decPending(1)
+	if _, ok := <-e.traverseResultVIDCh; ok {
+		t.Fatalf("expected traverseResultVIDCh to be closed once pendingTasks reaches 0 after childDone")
+	}
+	if !e.done {
+		t.Fatalf("expected e.done to be true")
+	}
+}
+
+func TestFinishIsIdempotent(t *testing.T) {
+	e := newTestTraverseExecutor()
+	e.markChildDone() // pendingTasks is already 0, so this alone should finish
+	e.finish()        // must not panic on a second close
+	if _, ok := <-e.traverseResultVIDCh; ok {
+		t.Fatalf("expected traverseResultVIDCh to be closed")
+	}
+}
+
+func TestResolveMaxPendingTasks(t *testing.T) {
+	cases := []struct {
+		sessionVar int64
+		want       int64
+	}{
+		{sessionVar: 0, want: defaultMaxPendingTasks},
+		{sessionVar: -1, want: defaultMaxPendingTasks},
+		{sessionVar: 42, want: 42},
+	}
+	for _, c := range cases {
+		if got := resolveMaxPendingTasks(c.sessionVar); got != c.want {
+			t.Fatalf("resolveMaxPendingTasks(%d) = %d, want %d", c.sessionVar, got, c.want)
+		}
+	}
+}
+
+// TestInitLifecycleDrivesNextAndCloseWithoutPanic exercises initLifecycle -
+// the exact code Open runs to wire up workerWg, taskCond, the dispatcher and
+// worker pool - followed by real Next/Close calls. workerWg used to be typed
+// *sync.WaitGroup with nothing ever setting it, so the first e.workerWg.Add(1)
+// in this path paniced with a nil-pointer dereference on every query;
+// Open/Next/Close can't be driven fully end-to-end without a live
+// txn/snapshot/child executor (none of which this package can construct
+// in isolation), so this test calls everything Open does except the
+// txn/snapshot/child wiring that precedes initLifecycle in Open's body.
+func TestInitLifecycleDrivesNextAndCloseWithoutPanic(t *testing.T) {
+	e := &TraverseExecutor{}
+	e.maxPendingTasks = defaultMaxPendingTasks
+	e.initLifecycle(context.Background())
+	e.prepared = true // skip fetchFromChildAndBuildFirstTask, which needs a real child executor
+
+	wantErr := errors.New("child fetch failed")
+	e.fetchFromChildErr <- wantErr
+
+	req := chunk.NewChunkWithCapacity(nil, 0)
+	if err := e.Next(context.Background(), req); err != wantErr {
+		t.Fatalf("Next() error = %v, want %v", err, wantErr)
+	}
+
+	closeDone := make(chan error, 1)
+	go func() { closeDone <- e.Close() }()
+	select {
+	case err := <-closeDone:
+		if err != nil {
+			t.Fatalf("Close() = %v, want nil", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("Close did not return promptly; a worker is likely stuck (e.g. blocked on a nil workerWg)")
+	}
+}
+
+func TestPathVisitedNone(t *testing.T) {
+	bm := roaring64.New()
+	bm.Add(1)
+	if pathVisited(UniquenessNone, bm, 1, kv.Key("k1")) {
+		t.Fatalf("UniquenessNone must never report a vertex/edge as visited")
+	}
+	if pathVisited(UniquenessNone, nil, 1, kv.Key("k1")) {
+		t.Fatalf("UniquenessNone must never report a vertex/edge as visited, even with a nil bitmap")
+	}
+}
+
+func TestPathVisitedNode(t *testing.T) {
+	bm := roaring64.New()
+	bm.Add(7)
+	if !pathVisited(UniquenessNode, bm, 7, kv.Key("k1")) {
+		t.Fatalf("expected vertex 7 to be reported visited")
+	}
+	if pathVisited(UniquenessNode, bm, 8, kv.Key("k1")) {
+		t.Fatalf("vertex 8 was never added, should not be reported visited")
+	}
+	if pathVisited(UniquenessNode, nil, 7, kv.Key("k1")) {
+		t.Fatalf("a nil visited bitmap means nothing has been recorded yet")
+	}
+}
+
+func TestPathVisitedRelationship(t *testing.T) {
+	k1, k2 := kv.Key("edge1"), kv.Key("edge2")
+	bm := roaring64.New()
+	bm.Add(edgeKeyHash(k1))
+	if !pathVisited(UniquenessRelationship, bm, 42, k1) {
+		t.Fatalf("expected edge k1 to be reported visited regardless of resultID")
+	}
+	if pathVisited(UniquenessRelationship, bm, 42, k2) {
+		t.Fatalf("edge k2 was never added, should not be reported visited")
+	}
+}
+
+func TestExtendVisitedNoneReturnsNil(t *testing.T) {
+	bm := roaring64.New()
+	bm.Add(1)
+	if got := extendVisited(UniquenessNone, bm, 2, kv.Key("k1")); got != nil {
+		t.Fatalf("extendVisited(UniquenessNone, ...) = %v, want nil", got)
+	}
+}
+
+func TestExtendVisitedNodeClonesAndAdds(t *testing.T) {
+	orig := roaring64.New()
+	orig.Add(1)
+
+	next := extendVisited(UniquenessNode, orig, 2, kv.Key("k1"))
+	if !next.Contains(1) || !next.Contains(2) {
+		t.Fatalf("expected cloned bitmap to contain both the original and newly added vertex")
+	}
+	if orig.Contains(2) {
+		t.Fatalf("extendVisited must clone before mutating, not mutate the caller's bitmap in place")
+	}
+
+	first := extendVisited(UniquenessNode, nil, 3, kv.Key("k1"))
+	if !first.Contains(3) {
+		t.Fatalf("expected a fresh bitmap to be created on the first hop (nil visited)")
+	}
+}
+
+func TestExtendVisitedRelationshipAddsEdgeHash(t *testing.T) {
+	k1 := kv.Key("edge1")
+	next := extendVisited(UniquenessRelationship, nil, 99, k1)
+	if !next.Contains(edgeKeyHash(k1)) {
+		t.Fatalf("expected extended bitmap to contain the edge's hash")
+	}
+	if next.Contains(99) {
+		t.Fatalf("RELATIONSHIP uniqueness must key by edge hash, not resultID")
+	}
+}
+
+func TestDecideHopFixedLength(t *testing.T) {
+	cond := condition{minHops: 1, maxHops: 1}
+
+	last := decideHop(cond, 1, true)
+	if !last.emit || last.again || last.advance {
+		t.Fatalf("decideHop(1-1, hop=1, last) = %+v, want emit only", last)
+	}
+
+	notLast := decideHop(cond, 1, false)
+	if notLast.emit || notLast.again || !notLast.advance {
+		t.Fatalf("decideHop(1-1, hop=1, !last) = %+v, want advance only", notLast)
+	}
+}
+
+func TestDecideHopVariableLengthBoundaries(t *testing.T) {
+	cond := condition{minHops: 2, maxHops: 4}
+
+	if dec := decideHop(cond, 1, true); dec.emit || dec.advance || !dec.again {
+		t.Fatalf("decideHop(2-4, hop=1 < minHops) = %+v, want again only (below range, must keep hopping)", dec)
+	}
+	if dec := decideHop(cond, 2, true); !dec.emit || !dec.again {
+		t.Fatalf("decideHop(2-4, hop=minHops) = %+v, want emit and again (in range, not yet at maxHops)", dec)
+	}
+	if dec := decideHop(cond, 3, true); !dec.emit || !dec.again {
+		t.Fatalf("decideHop(2-4, hop=maxHops-1) = %+v, want emit and again", dec)
+	}
+	if dec := decideHop(cond, 4, true); !dec.emit || dec.again {
+		t.Fatalf("decideHop(2-4, hop=maxHops) = %+v, want emit but not again (at the cap)", dec)
+	}
+}
+
+func TestDecideHopZeroMinHopsPassThrough(t *testing.T) {
+	cond := condition{minHops: 0, maxHops: 2}
+
+	if dec := decideHop(cond, 0, true); !dec.emit || !dec.again {
+		t.Fatalf("decideHop(0-2, hop=0, last) = %+v, want emit (zero-hop pass-through) and again", dec)
+	}
+	if dec := decideHop(cond, 0, false); dec.emit || !dec.advance {
+		t.Fatalf("decideHop(0-2, hop=0, !last) = %+v, want advance (zero-hop pass-through) only", dec)
+	}
+}
+
+// TestSeedVisitedMatchesModeNotJustNone guards the fix for a reviewer-flagged
+// bug: a frontier advancing into conditionChain's next condition used to
+// carry over the condition it was leaving's visited bitmap (via
+// extendVisited) instead of reseeding for the entry condition's own
+// uniqueness mode, so a NONE condition followed by a NODE one would enter
+// with a nil bitmap and never prune a self-loop on its own first hop.
+func TestSeedVisitedMatchesModeNotJustNone(t *testing.T) {
+	if got := seedVisited(UniquenessNone, 7); got != nil {
+		t.Fatalf("seedVisited(NONE, ...) = %v, want nil", got)
+	}
+
+	node := seedVisited(UniquenessNode, 7)
+	if node == nil || !node.Contains(7) {
+		t.Fatalf("seedVisited(NODE, 7) = %v, want a bitmap containing 7", node)
+	}
+
+	rel := seedVisited(UniquenessRelationship, 7)
+	if rel == nil || rel.GetCardinality() != 0 {
+		t.Fatalf("seedVisited(RELATIONSHIP, 7) = %v, want a fresh empty bitmap", rel)
+	}
+}
+
+func TestAppendVisited(t *testing.T) {
+	if got := appendVisited([]*roaring64.Bitmap{roaring64.New()}, nil); got != nil {
+		t.Fatalf("appendVisited(..., nil) = %v, want nil, since a NONE-mode parent has no visited slice at all", got)
+	}
+
+	child := roaring64.New()
+	child.Add(5)
+	got := appendVisited(nil, child)
+	if len(got) != 1 || got[0] != child {
+		t.Fatalf("expected appendVisited to append child onto the (possibly nil) visited slice")
+	}
+}